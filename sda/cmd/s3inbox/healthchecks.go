@@ -1,26 +1,45 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/heptiolabs/healthcheck"
 	"github.com/neicnordic/sensitive-data-archive/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/crypto/acme/autocert"
 )
 
+// defaultCheckInterval is used for the background S3/broker probes when
+// conf.Healthcheck.Interval is not set.
+const defaultCheckInterval = 10 * time.Second
+
 // HealthCheck registers and endpoint for healthchecking the service
 type HealthCheck struct {
-	port       int
-	DB         *sql.DB
-	s3URL      string
-	brokerURL  string
-	tlsConfig  *tls.Config
-	serverCert string
-	serverKey  string
+	port         int
+	DB           *sql.DB
+	s3URL        string
+	brokerURL    string
+	tlsConfig    *tls.Config
+	serverCert   string
+	serverKey    string
+	registry     *prometheus.Registry
+	statusGauge  *prometheus.GaugeVec
+	interval     time.Duration
+	ctx          context.Context
+	cancel       context.CancelFunc
+	handler      healthcheck.Handler
+	shuttingDown int32
+	grpcPort     int
+	autocertMgr  *autocert.Manager
 }
 
 // NewHealthCheck creates a new healthchecker. It needs to know where to find
@@ -39,32 +58,124 @@ func NewHealthCheck(port int, db *sql.DB, conf *config.Config, tlsConfig *tls.Co
 	serverCert := conf.Server.Cert
 	serverKey := conf.Server.Key
 
-	return &HealthCheck{port, db, s3URL, brokerURL, tlsConfig, serverCert, serverKey}
+	registry := prometheus.NewRegistry()
+	statusGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "healthcheck_status",
+		Help: "Result of the last run of a liveness/readiness check, 1 for healthy and 0 for failing",
+	}, []string{"check"})
+	registry.MustRegister(statusGauge)
+
+	interval := conf.Healthcheck.Interval
+	if interval <= 0 {
+		interval = defaultCheckInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var autocertMgr *autocert.Manager
+	if conf.Server.AutoCertDomains != "" {
+		var err error
+		autocertMgr, err = newAutocertManager(conf)
+		if err != nil {
+			panic(err)
+		}
+		// AutoCertDomains takes precedence: any static cert/key pair is
+		// ignored when it is set.
+		serverCert, serverKey = "", ""
+	}
+
+	h := &HealthCheck{port, db, s3URL, brokerURL, tlsConfig, serverCert, serverKey, registry, statusGauge, interval, ctx, cancel, healthcheck.NewHandler(), 0, conf.Healthcheck.GRPCPort, autocertMgr}
+	h.registerChecks()
+
+	return h
 }
 
-// RunHealthChecks should be run as a go routine in the main app. It registers
-// the healthcheck handler on the port specified in when creating a new
-// healthcheck.
-func (h *HealthCheck) RunHealthChecks() {
-	health := healthcheck.NewHandler()
+// registerChecks wires up the liveness/readiness checks on h.handler. It is
+// called once from NewHealthCheck so that the same handler, and the same
+// shutdown check, is shared by RunHealthChecks and any other server (e.g. the
+// gRPC health service) built on top of it.
+func (h *HealthCheck) registerChecks() {
+	h.handler.AddLivenessCheck("goroutine-threshold", h.observe("goroutine-threshold", healthcheck.GoroutineCountCheck(100)))
+
+	h.handler.AddReadinessCheck("S3-backend-http", h.AddAsyncReadinessCheck("S3-backend-http", h.httpsGetCheck(h.s3URL, 5000*time.Millisecond), h.interval))
 
-	health.AddLivenessCheck("goroutine-threshold", healthcheck.GoroutineCountCheck(100))
+	h.handler.AddReadinessCheck("broker-tcp", h.AddAsyncReadinessCheck("broker-tcp", healthcheck.TCPDialCheck(h.brokerURL, 5000*time.Millisecond), h.interval))
 
-	health.AddReadinessCheck("S3-backend-http", h.httpsGetCheck(h.s3URL, 5000*time.Millisecond))
+	h.handler.AddReadinessCheck("database", h.observe("database", healthcheck.DatabasePingCheck(h.DB, 1*time.Second)))
 
-	health.AddReadinessCheck("broker-tcp", healthcheck.TCPDialCheck(h.brokerURL, 5000*time.Millisecond))
+	h.handler.AddReadinessCheck("shutdown", h.observe("shutdown", h.shutdownCheck))
+}
 
-	health.AddReadinessCheck("database", healthcheck.DatabasePingCheck(h.DB, 1*time.Second))
+// shutdownCheck is a manual readiness check that starts failing as soon as
+// Shutdown is called.
+func (h *HealthCheck) shutdownCheck() error {
+	if atomic.LoadInt32(&h.shuttingDown) == 1 {
+		return fmt.Errorf("service is shutting down")
+	}
 
+	return nil
+}
+
+// Shutdown marks the service as not ready and stops the background
+// readiness checks. Callers should invoke it on SIGTERM before closing the
+// DB/broker connections, so that Kubernetes stops routing traffic to the pod
+// while in-flight work drains.
+func (h *HealthCheck) Shutdown() {
+	atomic.StoreInt32(&h.shuttingDown, 1)
+	h.cancel()
+}
+
+// AddAsyncReadinessCheck wraps check so that it runs in a background
+// goroutine on the given interval instead of on every probe request, and
+// caches the last result for the HTTP handler to return. The goroutine is
+// stopped when h's context is cancelled.
+func (h *HealthCheck) AddAsyncReadinessCheck(name string, check healthcheck.Check, interval time.Duration) healthcheck.Check {
+	return h.observe(name, healthcheck.AsyncWithContext(h.ctx, check, interval))
+}
+
+// observe wraps a healthcheck.Check so that its outcome is also recorded on
+// the statusGauge under the given name, in addition to being returned to the
+// caller unchanged.
+func (h *HealthCheck) observe(name string, check healthcheck.Check) healthcheck.Check {
+	return func() error {
+		err := check()
+		if err != nil {
+			h.statusGauge.WithLabelValues(name).Set(0)
+		} else {
+			h.statusGauge.WithLabelValues(name).Set(1)
+		}
+
+		return err
+	}
+}
+
+// healthy evaluates h.handler's aggregated readiness result without going
+// over the network, so it can be reused by non-HTTP front ends such as the
+// gRPC health service.
+func (h *HealthCheck) healthy() bool {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+	h.handler.ReadyEndpoint(rec, req)
+
+	return rec.Code == http.StatusOK
+}
+
+// RunHealthChecks should be run as a go routine in the main app. It registers
+// the healthcheck handler on the port specified in when creating a new
+// healthcheck.
+func (h *HealthCheck) RunHealthChecks() {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodHead {
 			// readyEndpoint does not accept method head
 			r.Method = http.MethodGet
-			health.ReadyEndpoint(w, r)
+			h.handler.ReadyEndpoint(w, r)
 		}
 	})
-	mux.HandleFunc("/health", health.ReadyEndpoint)
+	mux.HandleFunc("/health", h.handler.ReadyEndpoint)
+	mux.HandleFunc("/live", h.handler.LiveEndpoint)
+	mux.HandleFunc("/ready", h.handler.ReadyEndpoint)
+	mux.Handle("/metrics", promhttp.HandlerFor(h.registry, promhttp.HandlerOpts{}))
 
 	addr := ":" + strconv.Itoa(h.port)
 	server := &http.Server{
@@ -75,7 +186,18 @@ func (h *HealthCheck) RunHealthChecks() {
 		IdleTimeout:       30 * time.Second,
 		ReadHeaderTimeout: 3 * time.Second,
 	}
-	if h.serverCert != "" && h.serverKey != "" {
+
+	if h.autocertMgr != nil {
+		server.TLSConfig = h.autocertMgr.TLSConfig()
+		go func() {
+			if err := http.ListenAndServe(":80", h.autocertMgr.HTTPHandler(nil)); err != nil { //nolint:gosec
+				panic(err)
+			}
+		}()
+		if err := server.ListenAndServeTLS("", ""); err != nil {
+			panic(err)
+		}
+	} else if h.serverCert != "" && h.serverKey != "" {
 		if err := server.ListenAndServeTLS(h.serverCert, h.serverKey); err != nil {
 			panic(err)
 		}
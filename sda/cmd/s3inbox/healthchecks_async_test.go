@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestAddAsyncReadinessCheckRunsInBackgroundAndStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &HealthCheck{
+		statusGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_async_status"}, []string{"check"}),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	var calls int32
+	check := func() error {
+		atomic.AddInt32(&calls, 1)
+
+		return nil
+	}
+
+	cached := h.AddAsyncReadinessCheck("async-check", check, 10*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&calls) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if atomic.LoadInt32(&calls) == 0 {
+		t.Fatal("expected the check to run at least once in the background")
+	}
+	if err := cached(); err != nil {
+		t.Fatalf("cached check returned unexpected error: %v", err)
+	}
+
+	h.cancel()
+	time.Sleep(20 * time.Millisecond)
+	before := atomic.LoadInt32(&calls)
+	time.Sleep(50 * time.Millisecond)
+	if after := atomic.LoadInt32(&calls); after != before {
+		t.Errorf("check kept running after context cancellation: before=%d after=%d", before, after)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/neicnordic/sensitive-data-archive/internal/config"
+	"github.com/neicnordic/sensitive-data-archive/internal/storage"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newAutocertManager builds an autocert.Manager for conf.Server.AutoCertDomains,
+// caching issued certificates either on local disk or in the S3 inbox
+// depending on conf.Server.AutoCertCache.
+func newAutocertManager(conf *config.Config) (*autocert.Manager, error) {
+	domains := strings.Split(conf.Server.AutoCertDomains, ",")
+	for i := range domains {
+		domains[i] = strings.TrimSpace(domains[i])
+	}
+
+	cache, err := newAutocertCache(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      cache,
+	}, nil
+}
+
+// newAutocertCache returns a local disk cache, or an S3-backed one when
+// conf.Server.AutoCertCache is an s3:// path.
+func newAutocertCache(conf *config.Config) (autocert.Cache, error) {
+	path := conf.Server.AutoCertCache
+	if !strings.HasPrefix(path, "s3://") {
+		return autocert.DirCache(path), nil
+	}
+
+	backend, err := storage.NewBackend(conf.Inbox.S3)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.TrimPrefix(path, "s3://")
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+
+	return &s3AutocertCache{
+		backend: backend,
+		prefix:  prefix,
+	}, nil
+}
+
+// s3AutocertCache implements autocert.Cache on top of the project's own S3
+// backend, so certificates survive pod restarts without a local volume.
+type s3AutocertCache struct {
+	backend storage.Backend
+	prefix  string
+}
+
+func (c *s3AutocertCache) Get(_ context.Context, key string) ([]byte, error) {
+	r, err := c.backend.NewFileReader(c.prefix + key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, autocert.ErrCacheMiss
+		}
+
+		return nil, fmt.Errorf("autocert cache get %q: %w", key, err)
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+func (c *s3AutocertCache) Put(_ context.Context, key string, data []byte) error {
+	w, err := c.backend.NewFileWriter(c.prefix + key)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	_, err = w.Write(data)
+
+	return err
+}
+
+func (c *s3AutocertCache) Delete(_ context.Context, key string) error {
+	return c.backend.RemoveFile(c.prefix + key)
+}
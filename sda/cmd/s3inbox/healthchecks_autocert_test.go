@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/neicnordic/sensitive-data-archive/internal/config"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// fakeBackend is an in-memory storage.Backend used to exercise s3AutocertCache
+// without a real S3 endpoint.
+type fakeBackend struct {
+	files map[string][]byte
+	// readErr, when set, is returned from NewFileReader for any key that
+	// isn't already present in files - to simulate a transient backend
+	// error rather than a genuine not-found.
+	readErr error
+}
+
+func (b *fakeBackend) NewFileReader(name string) (io.ReadCloser, error) {
+	data, ok := b.files[name]
+	if !ok {
+		if b.readErr != nil {
+			return nil, b.readErr
+		}
+
+		return nil, os.ErrNotExist
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (b *fakeBackend) NewFileWriter(name string) (io.WriteCloser, error) {
+	return &fakeWriter{backend: b, name: name}, nil
+}
+
+func (b *fakeBackend) RemoveFile(name string) error {
+	delete(b.files, name)
+
+	return nil
+}
+
+type fakeWriter struct {
+	backend *fakeBackend
+	name    string
+	buf     bytes.Buffer
+}
+
+func (w *fakeWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *fakeWriter) Close() error {
+	w.backend.files[w.name] = w.buf.Bytes()
+
+	return nil
+}
+
+func TestS3AutocertCachePutGetRoundtrip(t *testing.T) {
+	c := &s3AutocertCache{backend: &fakeBackend{files: map[string][]byte{}}, prefix: "autocert/"}
+
+	if err := c.Put(context.Background(), "example.com", []byte("cert-data")); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+
+	got, err := c.Get(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if string(got) != "cert-data" {
+		t.Errorf("Get() = %q, want %q", got, "cert-data")
+	}
+
+	if err := c.Delete(context.Background(), "example.com"); err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	if _, err := c.Get(context.Background(), "example.com"); !errors.Is(err, autocert.ErrCacheMiss) {
+		t.Errorf("Get() after Delete() = %v, want autocert.ErrCacheMiss", err)
+	}
+}
+
+func TestNewAutocertCacheNormalizesS3Prefix(t *testing.T) {
+	conf := &config.Config{
+		Server: config.ServerConfig{AutoCertCache: "s3://my-bucket/autocert"},
+	}
+
+	cache, err := newAutocertCache(conf)
+	if err != nil {
+		t.Fatalf("newAutocertCache() = %v", err)
+	}
+
+	s3Cache, ok := cache.(*s3AutocertCache)
+	if !ok {
+		t.Fatalf("newAutocertCache() returned %T, want *s3AutocertCache", cache)
+	}
+
+	if want := "my-bucket/autocert/"; s3Cache.prefix != want {
+		t.Errorf("prefix = %q, want %q (no-trailing-slash AutoCertCache values must not mangle keys)", s3Cache.prefix, want)
+	}
+}
+
+func TestS3AutocertCacheGetPropagatesNonNotFoundErrors(t *testing.T) {
+	backendErr := errors.New("s3: connection refused")
+	c := &s3AutocertCache{backend: &fakeBackend{files: map[string][]byte{}, readErr: backendErr}}
+
+	_, err := c.Get(context.Background(), "example.com")
+	if err == nil || errors.Is(err, autocert.ErrCacheMiss) {
+		t.Fatalf("Get() = %v, want a wrapped backend error, not autocert.ErrCacheMiss", err)
+	}
+	if !errors.Is(err, backendErr) {
+		t.Errorf("Get() error does not wrap the backend error: %v", err)
+	}
+}
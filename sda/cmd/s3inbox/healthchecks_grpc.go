@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// watchDebounceInterval is how often a Watch stream re-evaluates the
+// underlying checks to look for a status transition to report.
+const watchDebounceInterval = 5 * time.Second
+
+// grpcHealthServer implements the gRPC Health Checking Protocol
+// (grpc.health.v1.Health) on top of the same liveness/readiness checks that
+// back the HTTP /live and /ready endpoints.
+type grpcHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	h *HealthCheck
+}
+
+// Check implements grpc.health.v1.Health, re-evaluating the readiness checks
+// on every call. Only the overall server status (the empty service name) is
+// known; any other service name is reported as NotFound, per the protocol.
+func (g *grpcHealthServer) Check(_ context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if req.GetService() != "" {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+
+	return &grpc_health_v1.HealthCheckResponse{Status: g.status()}, nil
+}
+
+// Watch implements grpc.health.v1.Health, streaming a new response whenever
+// the aggregated status changes.
+func (g *grpcHealthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	if req.GetService() != "" {
+		return status.Error(codes.NotFound, "unknown service")
+	}
+
+	ticker := time.NewTicker(watchDebounceInterval)
+	defer ticker.Stop()
+
+	last := grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN
+	for {
+		if status := g.status(); status != last {
+			if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: status}); err != nil {
+				return err
+			}
+			last = status
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (g *grpcHealthServer) status() grpc_health_v1.HealthCheckResponse_ServingStatus {
+	if g.h.healthy() {
+		return grpc_health_v1.HealthCheckResponse_SERVING
+	}
+
+	return grpc_health_v1.HealthCheckResponse_NOT_SERVING
+}
+
+// RunGRPCHealthChecks starts a gRPC server implementing the Health Checking
+// Protocol on conf.Healthcheck.GRPCPort, secured with the same certificate
+// used for the HTTPS health endpoint. It is a no-op if no gRPC port was
+// configured.
+func (h *HealthCheck) RunGRPCHealthChecks() {
+	if h.grpcPort == 0 {
+		return
+	}
+
+	lis, err := net.Listen("tcp", ":"+strconv.Itoa(h.grpcPort))
+	if err != nil {
+		panic(err)
+	}
+
+	var opts []grpc.ServerOption
+	switch {
+	case h.autocertMgr != nil:
+		// Reuse the same autocert-issued certificate as the HTTPS health
+		// endpoint, instead of falling back to a plaintext listener.
+		tlsConfig := h.tlsConfig.Clone()
+		tlsConfig.GetCertificate = h.autocertMgr.GetCertificate
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	case h.serverCert != "" && h.serverKey != "":
+		cert, err := tls.LoadX509KeyPair(h.serverCert, h.serverKey)
+		if err != nil {
+			panic(err)
+		}
+
+		tlsConfig := h.tlsConfig.Clone()
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	server := grpc.NewServer(opts...)
+	grpc_health_v1.RegisterHealthServer(server, &grpcHealthServer{h: h})
+
+	if err := server.Serve(lis); err != nil {
+		panic(err)
+	}
+}
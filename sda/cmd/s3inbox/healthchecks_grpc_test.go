@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/heptiolabs/healthcheck"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCHealthServerStatus(t *testing.T) {
+	handler := healthcheck.NewHandler()
+	ready := true
+	handler.AddReadinessCheck("toggle", func() error {
+		if ready {
+			return nil
+		}
+
+		return errors.New("not ready")
+	})
+
+	g := &grpcHealthServer{h: &HealthCheck{handler: handler}}
+
+	if got := g.status(); got != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Errorf("status() = %v, want SERVING", got)
+	}
+
+	ready = false
+	if got := g.status(); got != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status() = %v, want NOT_SERVING", got)
+	}
+}
+
+func TestGRPCHealthServerCheckRejectsUnknownService(t *testing.T) {
+	g := &grpcHealthServer{h: &HealthCheck{handler: healthcheck.NewHandler()}}
+
+	_, err := g.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "unknown"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Check() for an unknown service returned %v, want codes.NotFound", err)
+	}
+}
+
+// fakeWatchServer satisfies grpc_health_v1.Health_WatchServer without a real
+// gRPC stream. Its embedded interface is left nil, which is fine as long as
+// the test never drives Watch() past the point where it would call Send,
+// Context, or any other promoted method.
+type fakeWatchServer struct {
+	grpc_health_v1.Health_WatchServer
+}
+
+func TestGRPCHealthServerWatchRejectsUnknownService(t *testing.T) {
+	g := &grpcHealthServer{h: &HealthCheck{handler: healthcheck.NewHandler()}}
+
+	err := g.Watch(&grpc_health_v1.HealthCheckRequest{Service: "unknown"}, &fakeWatchServer{})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Watch() for an unknown service returned %v, want codes.NotFound", err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestShutdownCheck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &HealthCheck{ctx: ctx, cancel: cancel}
+
+	if err := h.shutdownCheck(); err != nil {
+		t.Fatalf("shutdownCheck before Shutdown() = %v, want nil", err)
+	}
+
+	h.Shutdown()
+
+	if err := h.shutdownCheck(); err == nil {
+		t.Fatal("shutdownCheck after Shutdown() = nil, want an error")
+	}
+
+	if err := ctx.Err(); err == nil {
+		t.Fatal("Shutdown() did not cancel the context used by background checks")
+	}
+}
+
+func TestShutdownCheckUpdatesStatusGauge(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &HealthCheck{
+		ctx:         ctx,
+		cancel:      cancel,
+		statusGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_shutdown_status"}, []string{"check"}),
+	}
+
+	check := h.observe("shutdown", h.shutdownCheck)
+
+	if err := check(); err != nil {
+		t.Fatalf("check() before Shutdown() = %v, want nil", err)
+	}
+	if v := gaugeValue(t, h.statusGauge, "shutdown"); v != 1 {
+		t.Errorf("gauge before Shutdown() = %v, want 1", v)
+	}
+
+	h.Shutdown()
+
+	if err := check(); err == nil {
+		t.Fatal("check() after Shutdown() = nil, want an error")
+	}
+	if v := gaugeValue(t, h.statusGauge, "shutdown"); v != 0 {
+		t.Errorf("gauge after Shutdown() = %v, want 0", v)
+	}
+}
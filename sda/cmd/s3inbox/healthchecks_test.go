@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var errTest = errors.New("test check failure")
+
+func gaugeValue(t *testing.T, gauge *prometheus.GaugeVec, name string) float64 {
+	t.Helper()
+
+	m := &dto.Metric{}
+	if err := gauge.WithLabelValues(name).Write(m); err != nil {
+		t.Fatalf("failed to read gauge for %q: %v", name, err)
+	}
+
+	return m.GetGauge().GetValue()
+}
+
+func TestObserveRecordsGaugeValue(t *testing.T) {
+	h := &HealthCheck{
+		statusGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_healthcheck_status"}, []string{"check"}),
+	}
+
+	ok := h.observe("ok-check", func() error { return nil })
+	if err := ok(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v := gaugeValue(t, h.statusGauge, "ok-check"); v != 1 {
+		t.Errorf("gauge for a passing check = %v, want 1", v)
+	}
+
+	failing := h.observe("failing-check", func() error { return errTest })
+	if err := failing(); err == nil {
+		t.Fatal("expected error from failing check")
+	}
+	if v := gaugeValue(t, h.statusGauge, "failing-check"); v != 0 {
+		t.Errorf("gauge for a failing check = %v, want 0", v)
+	}
+}
@@ -0,0 +1,56 @@
+// Package config holds the runtime configuration for the s3inbox service.
+package config
+
+import "time"
+
+// Config is the top-level configuration struct, populated from the
+// service's configuration file/environment.
+type Config struct {
+	Broker      BrokerConfig
+	Inbox       InboxConfig
+	Server      ServerConfig
+	Healthcheck HealthcheckConfig
+}
+
+// BrokerConfig holds the connection details for the Message Broker.
+type BrokerConfig struct {
+	Host string
+	Port int
+}
+
+// InboxConfig holds the inbox's storage backend configuration.
+type InboxConfig struct {
+	S3 S3Config
+}
+
+// S3Config holds the connection details for an S3-compatible storage backend.
+type S3Config struct {
+	URL       string
+	Port      int
+	Readypath string
+}
+
+// ServerConfig holds the TLS configuration for the service's HTTP(S) listeners.
+type ServerConfig struct {
+	Cert string
+	Key  string
+
+	// AutoCertDomains, when set, is a comma-separated list of hostnames to
+	// request ACME/autocert certificates for instead of using Cert/Key.
+	AutoCertDomains string
+
+	// AutoCertCache is where issued certificates are cached: either a local
+	// directory, or an "s3://" path into the already-configured S3 inbox.
+	AutoCertCache string
+}
+
+// HealthcheckConfig holds the tunables for the healthcheck endpoints.
+type HealthcheckConfig struct {
+	// Interval controls how often the S3 and broker readiness checks run in
+	// the background, instead of on every probe request.
+	Interval time.Duration
+
+	// GRPCPort, when non-zero, enables a gRPC Health Checking Protocol
+	// server on that port alongside the HTTP endpoints.
+	GRPCPort int
+}